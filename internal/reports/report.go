@@ -0,0 +1,21 @@
+// Package reports lets users author small Lua scripts that tabulate
+// statistics over their library - chapters read per source, per
+// language, per month; unread backlog by manga; download sizes - and
+// runs them under the same sandbox limits as source scripts.
+package reports
+
+import "time"
+
+// maxReportSize is the largest a Report's Lua script may be; anything
+// bigger is rejected before it ever reaches the sandbox.
+const maxReportSize = 64 * 1024
+
+// Report is a user-authored Lua script that produces a Tabulation over
+// the library.
+type Report struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Lua       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
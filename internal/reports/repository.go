@@ -0,0 +1,57 @@
+package reports
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrReportTooLarge is returned when a Report's Lua script exceeds
+// maxReportSize.
+var ErrReportTooLarge = errors.New("reports: script exceeds the 64KB size limit")
+
+// Repository persists Report definitions, mirroring source.Repository's
+// shape for the source package.
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db}
+}
+
+// SaveReport creates or updates report, rejecting scripts over the size
+// limit before they ever reach the sandbox.
+func (r *Repository) SaveReport(report *Report) (*Report, error) {
+	if len(report.Lua) > maxReportSize {
+		return nil, ErrReportTooLarge
+	}
+
+	if err := r.db.Save(report).Error; err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (r *Repository) GetReports() ([]*Report, error) {
+	var reports []*Report
+	if err := r.db.Find(&reports).Error; err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (r *Repository) GetReportByID(id uint) (*Report, error) {
+	var report Report
+	if err := r.db.First(&report, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+func (r *Repository) DeleteReport(id uint) error {
+	return r.db.Delete(&Report{}, id).Error
+}
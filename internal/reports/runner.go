@@ -0,0 +1,82 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/faldez/tanoshi/internal/source"
+	lua "github.com/yuin/gopher-lua"
+	"gorm.io/gorm"
+	luar "layeh.com/gopher-luar"
+)
+
+// ErrNoTabulation is returned when a report script finishes without
+// leaving a *Tabulation on the stack.
+var ErrNoTabulation = errors.New("reports: script must return a Tabulation")
+
+// Runner executes Report scripts against the library under the same
+// sandbox limits (context timeout, memory ceiling, stripped stdlib) as
+// source scripts.
+type Runner struct {
+	db   *gorm.DB
+	opts *source.SourceOptions
+}
+
+// NewRunner builds a Runner. opts may be nil to use the sandbox defaults.
+func NewRunner(db *gorm.DB, opts *source.SourceOptions) *Runner {
+	return &Runner{db: db, opts: opts}
+}
+
+// Run executes report.Lua against read-only mangas/chapters/pages tables
+// and returns the Tabulation it builds.
+func (runner *Runner) Run(ctx context.Context, report *Report) (*Tabulation, error) {
+	l, opts := source.NewSandboxedState(runner.opts)
+	defer l.Close()
+
+	deadline, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	l.SetContext(deadline)
+
+	var mangas []*source.Manga
+	if err := runner.db.Find(&mangas).Error; err != nil {
+		return nil, err
+	}
+
+	var chapters []*source.Chapter
+	if err := runner.db.Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+
+	var pages []*source.Page
+	if err := runner.db.Find(&pages).Error; err != nil {
+		return nil, err
+	}
+
+	l.SetGlobal("mangas", luar.New(l, mangas))
+	l.SetGlobal("chapters", luar.New(l, chapters))
+	l.SetGlobal("pages", luar.New(l, pages))
+	l.SetGlobal("Tabulation", luar.NewType(l, Tabulation{}))
+	l.SetGlobal("Series", luar.NewType(l, Series{}))
+	l.SetGlobal("new_tabulation", luar.New(l, NewTabulation))
+
+	if err := l.DoString(report.Lua); err != nil {
+		if deadline.Err() == context.DeadlineExceeded {
+			return nil, source.ErrLuaTimeout
+		}
+		return nil, err
+	}
+
+	lv := l.Get(-1)
+	ud, ok := lv.(*lua.LUserData)
+	if !ok {
+		return nil, ErrNoTabulation
+	}
+
+	tab, ok := ud.Value.(*Tabulation)
+	if !ok {
+		return nil, fmt.Errorf("reports: script returned %T, want *Tabulation", ud.Value)
+	}
+
+	return tab, nil
+}
@@ -0,0 +1,30 @@
+package reports
+
+// Series is one named line in a Tabulation, e.g. chapters read per month
+// for a single source.
+type Series struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// Tabulation is what a report script returns: a shared set of labels
+// (the x-axis buckets, e.g. month names) plus one Series per line the
+// frontend renders as a table or chart.
+type Tabulation struct {
+	Labels []string           `json:"labels"`
+	Series map[string]*Series `json:"series"`
+}
+
+// NewTabulation returns an empty Tabulation ready for a report script to
+// populate.
+func NewTabulation() *Tabulation {
+	return &Tabulation{Series: make(map[string]*Series)}
+}
+
+// AddSeries appends a new, empty Series named name to the tabulation and
+// returns it so a report script can fill in its Values.
+func (t *Tabulation) AddSeries(name string) *Series {
+	s := &Series{Name: name}
+	t.Series[name] = s
+	return s
+}
@@ -0,0 +1,146 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultCacheTTL is the TTL LoadSourceFromPath enables caching with when
+// a source's Config doesn't set a more specific per-operation TTL via
+// Config.CacheTTL.
+const defaultCacheTTL = 15 * time.Minute
+
+// cacheEnabled is a package-level toggle so operators (or tests) can turn
+// response caching off entirely without touching every Source.
+var cacheEnabled = true
+
+// EnableCache turns on the on-disk HTTP response cache for all sources.
+// Caching is enabled by default.
+func EnableCache() {
+	cacheEnabled = true
+}
+
+// DisableCache turns off the on-disk HTTP response cache for all sources.
+// Existing cache entries on disk are left untouched.
+func DisableCache() {
+	cacheEnabled = false
+}
+
+type noCacheKey struct{}
+
+// WithNoCache returns a copy of ctx that instructs doRequest to bypass the
+// cache for this call, both for reads and for writing a fresh entry.
+func WithNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+func noCacheFromContext(ctx context.Context) bool {
+	v, _ := ctx.Value(noCacheKey{}).(bool)
+	return v
+}
+
+// cacheEntry is what gets persisted to disk for a single cached response.
+// Only 2xx responses are ever stored (see doRequest), so a cached entry
+// always represents a successful fetch.
+type cacheEntry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+func isCacheableStatus(code int) bool {
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
+
+// diskCache stores raw HTTP responses under the user's cache directory,
+// keyed by a hash of method+URL+headers+body so repeated scrapes of the
+// same request can be served without hitting the network again.
+type diskCache struct {
+	dir string
+}
+
+func newDiskCache() (*diskCache, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(base, "tanoshi", "source")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &diskCache{dir: dir}, nil
+}
+
+// cacheKey hashes method+URL+headers+body into a stable digest. Header
+// keys are sorted before hashing because req.Header is a Go map, and
+// ranging over a map in iteration order (as an earlier version of this
+// function did) makes the digest non-deterministic across calls for any
+// request with more than one header, silently degrading the cache to a
+// permanent miss.
+func cacheKey(req *http.Request, body []byte) string {
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(req.Method))
+	h.Write([]byte(req.URL.String()))
+	for _, k := range keys {
+		h.Write([]byte(k))
+		values := append([]string(nil), req.Header[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			h.Write([]byte(v))
+		}
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *diskCache) get(key string, ttl time.Duration) (*cacheEntry, bool) {
+	raw, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false
+	}
+
+	if !isCacheableStatus(entry.StatusCode) {
+		return nil, false
+	}
+
+	if ttl > 0 && time.Since(entry.StoredAt) > ttl {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (c *diskCache) put(key string, entry *cacheEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path(key), raw, 0o644)
+}
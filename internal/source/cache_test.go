@@ -0,0 +1,80 @@
+package source
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCacheKeyStableAcrossHeaderOrder guards against the bug where ranging
+// over req.Header (a Go map) in iteration order made cacheKey
+// non-deterministic for any request with more than one header, silently
+// turning the response cache into a permanent miss.
+func TestCacheKeyStableAcrossHeaderOrder(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/manga", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "tanoshi")
+	req.Header.Set("Referer", "https://example.com")
+
+	want := cacheKey(req, nil)
+	for i := 0; i < 20; i++ {
+		if got := cacheKey(req, nil); got != want {
+			t.Fatalf("cacheKey is non-deterministic across calls: got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	c := &diskCache{dir: t.TempDir()}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/manga", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "tanoshi")
+	req.Header.Set("Referer", "https://example.com")
+	key := cacheKey(req, nil)
+
+	entry := &cacheEntry{StatusCode: http.StatusOK, Body: "ok", StoredAt: time.Now()}
+	if err := c.put(key, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := c.get(key, time.Hour)
+	if !ok {
+		t.Fatal("expected cache hit after put")
+	}
+	if got.Body != entry.Body {
+		t.Fatalf("got body %q, want %q", got.Body, entry.Body)
+	}
+}
+
+func TestDiskCacheRejectsNonCacheableStatus(t *testing.T) {
+	c := &diskCache{dir: t.TempDir()}
+	key := "error-response"
+
+	entry := &cacheEntry{StatusCode: http.StatusTooManyRequests, Body: "rate limited", StoredAt: time.Now()}
+	if err := c.put(key, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.get(key, time.Hour); ok {
+		t.Fatal("expected a stored 429 entry to be rejected on read")
+	}
+}
+
+func TestDiskCacheRejectsExpiredEntry(t *testing.T) {
+	c := &diskCache{dir: t.TempDir()}
+	key := "expired"
+
+	entry := &cacheEntry{StatusCode: http.StatusOK, Body: "ok", StoredAt: time.Now().Add(-time.Hour)}
+	if err := c.put(key, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.get(key, time.Minute); ok {
+		t.Fatal("expected an entry older than ttl to be rejected")
+	}
+}
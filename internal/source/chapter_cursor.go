@@ -0,0 +1,69 @@
+package source
+
+import "sort"
+
+// chapterRank is the minimal per-chapter data a ChapterCursor needs to
+// order chapters and answer Seek queries.
+type chapterRank struct {
+	ID   uint    `gorm:"column:id"`
+	Rank float64 `gorm:"column:rank"`
+}
+
+// ChapterCursor is a compact, rank-sorted, in-memory index over a manga's
+// chapters for a fixed language set.
+type ChapterCursor struct {
+	MangaID   uint
+	Languages []string
+	entries   []chapterRank
+	indexByID map[uint]int
+}
+
+func newChapterCursor(mangaID uint, languages []string, entries []chapterRank) *ChapterCursor {
+	indexByID := make(map[uint]int, len(entries))
+	for i, e := range entries {
+		indexByID[e.ID] = i
+	}
+
+	return &ChapterCursor{
+		MangaID:   mangaID,
+		Languages: languages,
+		entries:   entries,
+		indexByID: indexByID,
+	}
+}
+
+// Next returns the id of the chapter ranked immediately after id, or 0 if
+// id is the last chapter, or isn't tracked by this cursor.
+func (c *ChapterCursor) Next(id uint) uint {
+	i, ok := c.indexByID[id]
+	if !ok || i+1 >= len(c.entries) {
+		return 0
+	}
+	return c.entries[i+1].ID
+}
+
+// Prev returns the id of the chapter ranked immediately before id, or 0 if
+// id is the first chapter, or isn't tracked by this cursor.
+func (c *ChapterCursor) Prev(id uint) uint {
+	i, ok := c.indexByID[id]
+	if !ok || i == 0 {
+		return 0
+	}
+	return c.entries[i-1].ID
+}
+
+// Seek returns the id of the first chapter at or after rank, using a
+// binary search over the rank-sorted entries, and false if rank is past
+// the last chapter.
+func (c *ChapterCursor) Seek(rank float64) (uint, bool) {
+	i := sort.Search(len(c.entries), func(i int) bool { return c.entries[i].Rank >= rank })
+	if i >= len(c.entries) {
+		return 0, false
+	}
+	return c.entries[i].ID, true
+}
+
+// Len reports how many chapters this cursor tracks.
+func (c *ChapterCursor) Len() int {
+	return len(c.entries)
+}
@@ -0,0 +1,52 @@
+package source
+
+import "testing"
+
+func testCursor() *ChapterCursor {
+	return newChapterCursor(1, nil, []chapterRank{
+		{ID: 10, Rank: 1},
+		{ID: 20, Rank: 2},
+		{ID: 30, Rank: 3},
+	})
+}
+
+func TestChapterCursorNextPrev(t *testing.T) {
+	c := testCursor()
+
+	if next := c.Next(10); next != 20 {
+		t.Errorf("Next(10) = %d, want 20", next)
+	}
+	if next := c.Next(30); next != 0 {
+		t.Errorf("Next(30) = %d, want 0 (last chapter)", next)
+	}
+	if next := c.Next(999); next != 0 {
+		t.Errorf("Next(999) = %d, want 0 (untracked id)", next)
+	}
+
+	if prev := c.Prev(30); prev != 20 {
+		t.Errorf("Prev(30) = %d, want 20", prev)
+	}
+	if prev := c.Prev(10); prev != 0 {
+		t.Errorf("Prev(10) = %d, want 0 (first chapter)", prev)
+	}
+}
+
+func TestChapterCursorSeek(t *testing.T) {
+	c := testCursor()
+
+	if id, ok := c.Seek(2); !ok || id != 20 {
+		t.Errorf("Seek(2) = (%d, %v), want (20, true)", id, ok)
+	}
+	if id, ok := c.Seek(1.5); !ok || id != 20 {
+		t.Errorf("Seek(1.5) = (%d, %v), want (20, true)", id, ok)
+	}
+	if _, ok := c.Seek(4); ok {
+		t.Error("Seek(4) = ok, want false (past the last chapter)")
+	}
+}
+
+func TestChapterCursorLen(t *testing.T) {
+	if got := testCursor().Len(); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+}
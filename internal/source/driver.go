@@ -0,0 +1,59 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Driver is the uniform interface every source backend implements,
+// whether it's a community Lua script, a first-class Go implementation,
+// or a declarative json:// manifest (see internal/source/manifest).
+// Repository.GetSources hands these out so the rest of the app never has
+// to know which backend a given source uses.
+type Driver interface {
+	GetLatestUpdates(ctx context.Context, page int) ([]*Manga, error)
+	GetMangaDetails(ctx context.Context, m *Manga) (*Manga, error)
+	GetChapters(ctx context.Context, m *Manga) ([]*Chapter, error)
+	GetChapter(ctx context.Context, c *Chapter) (*Chapter, error)
+	FetchManga(ctx context.Context, filter Filters) ([]*Manga, error)
+	Login(ctx context.Context, username, password, twoFactor string, remember bool) error
+}
+
+// DriverFactory builds a Driver from the part of a source URI that follows
+// the "scheme://" prefix, e.g. the "/path/to/source.lua" in
+// "lua:///path/to/source.lua".
+type DriverFactory func(rest string, opts *SourceOptions) (Driver, error)
+
+var driverFactories = map[string]DriverFactory{}
+
+// RegisterDriver makes a backend available under the given URL scheme.
+// Backend packages call this from an init() function, mirroring how
+// database/sql drivers register themselves.
+func RegisterDriver(scheme string, factory DriverFactory) {
+	driverFactories[scheme] = factory
+}
+
+// LoadDriver resolves uri's scheme (lua, go, or json) to a registered
+// backend and constructs it. opts is forwarded to backends that execute
+// untrusted code (currently only the lua driver) and is ignored
+// otherwise.
+func LoadDriver(uri string, opts *SourceOptions) (Driver, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return nil, fmt.Errorf("source: %q is not a scheme-qualified source URI", uri)
+	}
+
+	factory, ok := driverFactories[scheme]
+	if !ok {
+		return nil, fmt.Errorf("source: no driver registered for scheme %q", scheme)
+	}
+
+	return factory(rest, opts)
+}
+
+func init() {
+	RegisterDriver("lua", func(rest string, opts *SourceOptions) (Driver, error) {
+		return LoadSourceFromPath(rest, opts)
+	})
+}
@@ -0,0 +1,301 @@
+// Package mangadex is a first-class Go driver for MangaDex's public HTTP
+// API. It exists so users get a fast, well-tested MangaDex source without
+// installing a community Lua script, while still being a regular
+// source.Driver that the rest of the app treats like any other backend.
+package mangadex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/faldez/tanoshi/internal/source"
+)
+
+const (
+	apiBaseURL      = "https://api.mangadex.org"
+	uploadsBaseURL  = "https://uploads.mangadex.org"
+	chapterPageSize = 100
+)
+
+// ErrNotLoggedIn is returned by calls that require an authenticated
+// session before Login has completed successfully.
+var ErrNotLoggedIn = errors.New("mangadex: not logged in")
+
+// Driver talks to api.mangadex.org directly instead of going through a Lua
+// scraper. It satisfies source.Driver.
+type Driver struct {
+	httpClient *http.Client
+	sessionJWT string
+}
+
+// New constructs a MangaDex driver.
+func New() *Driver {
+	return &Driver{httpClient: &http.Client{}}
+}
+
+func init() {
+	// go://builtin/mangadex is currently the only registered "go" backend;
+	// additional builtins would extend this factory to route on rest.
+	source.RegisterDriver("go", func(rest string, _ *source.SourceOptions) (source.Driver, error) {
+		if rest != "builtin/mangadex" {
+			return nil, fmt.Errorf("mangadex: unknown builtin driver %q", rest)
+		}
+		return New(), nil
+	})
+}
+
+func (d *Driver) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := apiBaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if d.sessionJWT != "" {
+		req.Header.Set("Authorization", "Bearer "+d.sessionJWT)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("mangadex: %s returned %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type mangaAttributes struct {
+	Title map[string]string `json:"title"`
+}
+
+type relationship struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type mangaResource struct {
+	ID            string          `json:"id"`
+	Attributes    mangaAttributes `json:"attributes"`
+	Relationships []relationship  `json:"relationships"`
+}
+
+type mangaListResponse struct {
+	Data []mangaResource `json:"data"`
+}
+
+func (d *Driver) toManga(m mangaResource) *source.Manga {
+	cover := ""
+	for _, rel := range m.Relationships {
+		if rel.Type == "cover_art" {
+			cover = fmt.Sprintf("%s/covers/%s/%s", uploadsBaseURL, m.ID, rel.ID)
+		}
+	}
+
+	title := m.Attributes.Title["en"]
+	if title == "" {
+		for _, t := range m.Attributes.Title {
+			title = t
+			break
+		}
+	}
+
+	return &source.Manga{
+		Name:   title,
+		Path:   m.ID,
+		Source: "MangaDex",
+		Cover:  cover,
+	}
+}
+
+// GetLatestUpdates returns the most recently updated manga, newest first.
+func (d *Driver) GetLatestUpdates(ctx context.Context, page int) ([]*source.Manga, error) {
+	query := url.Values{
+		"limit":                        {"20"},
+		"offset":                       {strconv.Itoa((page - 1) * 20)},
+		"order[latestUploadedChapter]": {"desc"},
+	}
+
+	var res mangaListResponse
+	if err := d.get(ctx, "/manga", query, &res); err != nil {
+		return nil, err
+	}
+
+	mangas := make([]*source.Manga, 0, len(res.Data))
+	for _, m := range res.Data {
+		mangas = append(mangas, d.toManga(m))
+	}
+
+	return mangas, nil
+}
+
+// GetMangaDetails fetches title, cover and other metadata for m.Path.
+func (d *Driver) GetMangaDetails(ctx context.Context, m *source.Manga) (*source.Manga, error) {
+	var res struct {
+		Data mangaResource `json:"data"`
+	}
+	if err := d.get(ctx, "/manga/"+m.Path, url.Values{"includes[]": {"cover_art"}}, &res); err != nil {
+		return nil, err
+	}
+
+	details := d.toManga(res.Data)
+	details.ID = m.ID
+	return details, nil
+}
+
+type chapterAttributes struct {
+	Chapter            string `json:"chapter"`
+	Title              string `json:"title"`
+	TranslatedLanguage string `json:"translatedLanguage"`
+}
+
+type chapterResource struct {
+	ID         string            `json:"id"`
+	Attributes chapterAttributes `json:"attributes"`
+}
+
+type chapterListResponse struct {
+	Data  []chapterResource `json:"data"`
+	Total int               `json:"total"`
+}
+
+func (d *Driver) toChapter(m *source.Manga, c chapterResource) *source.Chapter {
+	rank, _ := strconv.ParseFloat(c.Attributes.Chapter, 64)
+	return &source.Chapter{
+		MangaID:  m.ID,
+		Path:     c.ID,
+		Name:     c.Attributes.Title,
+		Language: c.Attributes.TranslatedLanguage,
+		Rank:     rank,
+		Source:   "MangaDex",
+	}
+}
+
+// GetChapters returns every chapter of m across all translated languages,
+// paging through MangaDex's feed endpoint chapterPageSize at a time. Like
+// the Lua driver, it leaves per-language filtering to Repository, which
+// applies the enabled languages from the source's Config.
+func (d *Driver) GetChapters(ctx context.Context, m *source.Manga) ([]*source.Chapter, error) {
+	var chapters []*source.Chapter
+	for offset := 0; ; offset += chapterPageSize {
+		query := url.Values{
+			"limit":          {strconv.Itoa(chapterPageSize)},
+			"offset":         {strconv.Itoa(offset)},
+			"order[chapter]": {"asc"},
+		}
+
+		var res chapterListResponse
+		if err := d.get(ctx, "/manga/"+m.Path+"/feed", query, &res); err != nil {
+			return nil, err
+		}
+
+		for _, c := range res.Data {
+			chapters = append(chapters, d.toChapter(m, c))
+		}
+
+		if offset+chapterPageSize >= res.Total || len(res.Data) == 0 {
+			break
+		}
+	}
+
+	return chapters, nil
+}
+
+type atHomeResponse struct {
+	BaseURL string `json:"baseUrl"`
+	Chapter struct {
+		Hash string   `json:"hash"`
+		Data []string `json:"data"`
+	} `json:"chapter"`
+}
+
+// GetChapter resolves the at-home image server for c and returns its
+// pages.
+func (d *Driver) GetChapter(ctx context.Context, c *source.Chapter) (*source.Chapter, error) {
+	var res atHomeResponse
+	if err := d.get(ctx, "/at-home/server/"+c.Path, nil, &res); err != nil {
+		return nil, err
+	}
+
+	pages := make([]*source.Page, 0, len(res.Chapter.Data))
+	for _, filename := range res.Chapter.Data {
+		pages = append(pages, &source.Page{
+			ChapterID: c.ID,
+			URL:       fmt.Sprintf("%s/data/%s/%s", res.BaseURL, res.Chapter.Hash, filename),
+		})
+	}
+
+	c.Pages = pages
+	return c, nil
+}
+
+// FetchManga searches MangaDex's catalog using filter's title field.
+func (d *Driver) FetchManga(ctx context.Context, filter source.Filters) ([]*source.Manga, error) {
+	query := url.Values{"limit": {"20"}}
+	if title, ok := filter["title"].(string); ok && title != "" {
+		query.Set("title", title)
+	}
+
+	var res mangaListResponse
+	if err := d.get(ctx, "/manga", query, &res); err != nil {
+		return nil, err
+	}
+
+	mangas := make([]*source.Manga, 0, len(res.Data))
+	for _, m := range res.Data {
+		mangas = append(mangas, d.toManga(m))
+	}
+
+	return mangas, nil
+}
+
+// Login authenticates against MangaDex's legacy session endpoint and
+// keeps the returned JWT for subsequent requests. twoFactor and remember
+// are accepted for interface parity with the Lua driver but are unused by
+// MangaDex's API.
+func (d *Driver) Login(ctx context.Context, username, password, twoFactor string, remember bool) error {
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBaseURL+"/auth/login", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return ErrNotLoggedIn
+	}
+
+	var res struct {
+		Token struct {
+			Session string `json:"session"`
+		} `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+
+	d.sessionJWT = res.Token.Session
+	return nil
+}
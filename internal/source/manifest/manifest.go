@@ -0,0 +1,321 @@
+// Package manifest implements the json:// driver: a source.Driver built at
+// load time from a declarative JSON manifest file instead of compiled Go
+// code or a Lua script. It targets simple REST APIs that already return
+// JSON in a shape a few dotted field paths can describe, so wiring one up
+// doesn't need a community script or a dedicated Go backend like
+// internal/source/mangadex.
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/faldez/tanoshi/internal/source"
+)
+
+// FieldMap maps a Manga/Chapter/Page's fields to dotted paths into a
+// decoded JSON response, relative to the item being converted (a list
+// entry for list endpoints, the decoded body itself for single-resource
+// endpoints). A zero-value string leaves the corresponding field unset.
+type FieldMap struct {
+	ID       string `json:"id,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Cover    string `json:"cover,omitempty"`
+	Language string `json:"language,omitempty"`
+	Rank     string `json:"rank,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Endpoint describes one REST call: where to send it and how to read a
+// Manga/Chapter/Page out of its JSON response.
+type Endpoint struct {
+	// Path is joined onto the manifest's BaseURL. It may reference
+	// {page}, {path} and {query} placeholders, substituted from the
+	// call's arguments before the request is sent.
+	Path string `json:"path"`
+	// ListField is the dotted path to the response's result array. Left
+	// empty, the decoded response body itself is treated as the array.
+	// Single-resource endpoints (MangaDetails) ignore it and read Fields
+	// off the response body directly.
+	ListField string `json:"list_field,omitempty"`
+	// Fields maps this endpoint's items to Manga/Chapter/Page fields.
+	Fields FieldMap `json:"fields"`
+}
+
+// Manifest is the json:// source definition: a base URL plus one Endpoint
+// per capability a source.Driver can offer. Every Endpoint is optional;
+// calling a capability the manifest doesn't define returns
+// ErrNotSupported.
+type Manifest struct {
+	Name          string    `json:"name"`
+	BaseURL       string    `json:"base_url"`
+	LatestUpdates *Endpoint `json:"latest_updates,omitempty"`
+	MangaDetails  *Endpoint `json:"manga_details,omitempty"`
+	Chapters      *Endpoint `json:"chapters,omitempty"`
+	ChapterPages  *Endpoint `json:"chapter_pages,omitempty"`
+	Search        *Endpoint `json:"search,omitempty"`
+}
+
+// ErrNotSupported is returned by a Driver call whose Endpoint the manifest
+// didn't define.
+type ErrNotSupported struct {
+	Capability string
+}
+
+func (e *ErrNotSupported) Error() string {
+	return fmt.Sprintf("manifest: source does not define a %s endpoint", e.Capability)
+}
+
+// Driver serves a source.Driver from a Manifest, translating each call
+// into a plain HTTP GET against BaseURL+Endpoint.Path and walking the
+// JSON response per Endpoint.Fields. It satisfies source.Driver.
+type Driver struct {
+	manifest   *Manifest
+	httpClient *http.Client
+}
+
+// Load reads and parses the manifest file at path.
+func Load(path string) (*Driver, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("manifest: %s: %w", path, err)
+	}
+	if m.BaseURL == "" {
+		return nil, fmt.Errorf("manifest: %s: base_url is required", path)
+	}
+
+	return &Driver{manifest: &m, httpClient: &http.Client{}}, nil
+}
+
+func init() {
+	// json:///path/to/manifest.json is the only registered "json"
+	// backend so far; rest is the manifest file path.
+	source.RegisterDriver("json", func(rest string, _ *source.SourceOptions) (source.Driver, error) {
+		return Load(rest)
+	})
+}
+
+// buildURL substitutes {page}, {path} and {query} placeholders in
+// endpoint.Path and joins the result onto BaseURL.
+func (d *Driver) buildURL(endpoint *Endpoint, vars map[string]string) string {
+	path := endpoint.Path
+	for k, v := range vars {
+		path = strings.ReplaceAll(path, "{"+k+"}", url.QueryEscape(v))
+	}
+	return strings.TrimSuffix(d.manifest.BaseURL, "/") + path
+}
+
+func (d *Driver) get(url string) (interface{}, error) {
+	resp, err := d.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("manifest: %s returned %d", url, resp.StatusCode)
+	}
+
+	var decoded interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// lookup walks dotted path (e.g. "attributes.title.en") through a decoded
+// JSON value, returning nil if any segment is missing or not an object.
+func lookup(value interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[segment]
+	}
+
+	return value
+}
+
+func lookupString(value interface{}, path string) string {
+	v := lookup(value, path)
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func lookupFloat(value interface{}, path string) float64 {
+	v := lookup(value, path)
+	if f, ok := v.(float64); ok {
+		return f
+	}
+	if s, ok := v.(string); ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	return 0
+}
+
+// items returns the list endpoint.ListField points at, or the decoded
+// response itself if ListField is empty.
+func items(decoded interface{}, listField string) []interface{} {
+	value := decoded
+	if listField != "" {
+		value = lookup(decoded, listField)
+	}
+
+	list, _ := value.([]interface{})
+	return list
+}
+
+func (d *Driver) toManga(item interface{}, fields FieldMap) *source.Manga {
+	return &source.Manga{
+		Name:   lookupString(item, fields.Title),
+		Path:   lookupString(item, fields.Path),
+		Source: d.manifest.Name,
+		Cover:  lookupString(item, fields.Cover),
+	}
+}
+
+func (d *Driver) toChapter(m *source.Manga, item interface{}, fields FieldMap) *source.Chapter {
+	return &source.Chapter{
+		MangaID:  m.ID,
+		Path:     lookupString(item, fields.Path),
+		Name:     lookupString(item, fields.Title),
+		Language: lookupString(item, fields.Language),
+		Rank:     lookupFloat(item, fields.Rank),
+		Source:   d.manifest.Name,
+	}
+}
+
+// GetLatestUpdates returns the latest_updates endpoint's results for page.
+func (d *Driver) GetLatestUpdates(ctx context.Context, page int) ([]*source.Manga, error) {
+	endpoint := d.manifest.LatestUpdates
+	if endpoint == nil {
+		return nil, &ErrNotSupported{Capability: "latest_updates"}
+	}
+
+	decoded, err := d.get(d.buildURL(endpoint, map[string]string{"page": strconv.Itoa(page)}))
+	if err != nil {
+		return nil, err
+	}
+
+	var mangas []*source.Manga
+	for _, item := range items(decoded, endpoint.ListField) {
+		mangas = append(mangas, d.toManga(item, endpoint.Fields))
+	}
+
+	return mangas, nil
+}
+
+// GetMangaDetails fetches the manga_details endpoint for m.Path.
+func (d *Driver) GetMangaDetails(ctx context.Context, m *source.Manga) (*source.Manga, error) {
+	endpoint := d.manifest.MangaDetails
+	if endpoint == nil {
+		return nil, &ErrNotSupported{Capability: "manga_details"}
+	}
+
+	decoded, err := d.get(d.buildURL(endpoint, map[string]string{"path": m.Path}))
+	if err != nil {
+		return nil, err
+	}
+
+	details := d.toManga(decoded, endpoint.Fields)
+	details.ID = m.ID
+	return details, nil
+}
+
+// GetChapters returns the chapters endpoint's results for m.Path. Like the
+// lua and mangadex drivers, it leaves per-language filtering to
+// Repository, which applies the enabled languages from the source's
+// Config.
+func (d *Driver) GetChapters(ctx context.Context, m *source.Manga) ([]*source.Chapter, error) {
+	endpoint := d.manifest.Chapters
+	if endpoint == nil {
+		return nil, &ErrNotSupported{Capability: "chapters"}
+	}
+
+	decoded, err := d.get(d.buildURL(endpoint, map[string]string{"path": m.Path}))
+	if err != nil {
+		return nil, err
+	}
+
+	var chapters []*source.Chapter
+	for _, item := range items(decoded, endpoint.ListField) {
+		chapters = append(chapters, d.toChapter(m, item, endpoint.Fields))
+	}
+
+	return chapters, nil
+}
+
+// GetChapter fetches the chapter_pages endpoint for c.Path and returns its
+// pages.
+func (d *Driver) GetChapter(ctx context.Context, c *source.Chapter) (*source.Chapter, error) {
+	endpoint := d.manifest.ChapterPages
+	if endpoint == nil {
+		return nil, &ErrNotSupported{Capability: "chapter_pages"}
+	}
+
+	decoded, err := d.get(d.buildURL(endpoint, map[string]string{"path": c.Path}))
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []*source.Page
+	for _, item := range items(decoded, endpoint.ListField) {
+		pages = append(pages, &source.Page{
+			ChapterID: c.ID,
+			URL:       lookupString(item, endpoint.Fields.URL),
+		})
+	}
+
+	c.Pages = pages
+	return c, nil
+}
+
+// FetchManga searches using the search endpoint with filter's title field.
+func (d *Driver) FetchManga(ctx context.Context, filter source.Filters) ([]*source.Manga, error) {
+	endpoint := d.manifest.Search
+	if endpoint == nil {
+		return nil, &ErrNotSupported{Capability: "search"}
+	}
+
+	query, _ := filter["title"].(string)
+	decoded, err := d.get(d.buildURL(endpoint, map[string]string{"query": query}))
+	if err != nil {
+		return nil, err
+	}
+
+	var mangas []*source.Manga
+	for _, item := range items(decoded, endpoint.ListField) {
+		mangas = append(mangas, d.toManga(item, endpoint.Fields))
+	}
+
+	return mangas, nil
+}
+
+// Login is unimplemented: a manifest describes stateless GET endpoints
+// only, with no place to declare how credentials should be sent. Sources
+// that need authentication belong in a dedicated Go driver (like
+// internal/source/mangadex) or a Lua script instead.
+func (d *Driver) Login(ctx context.Context, username, password, twoFactor string, remember bool) error {
+	return &ErrNotSupported{Capability: "login"}
+}
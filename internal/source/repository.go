@@ -3,7 +3,10 @@ package source
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -15,34 +18,50 @@ var (
 
 type Repository struct {
 	db *gorm.DB
+
+	cursorsMu sync.RWMutex
+	cursors   map[string]*ChapterCursor
 }
 
 func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db}
+	return &Repository{db: db, cursors: make(map[string]*ChapterCursor)}
 }
 
-func (r *Repository) GetSources() (map[string]SourceInterface, error) {
+// GetSources loads every configured source row and resolves it to a Driver
+// via its URL scheme (lua://, go://, or json://), so callers get a uniform
+// Driver regardless of which backend actually serves a given source.
+func (r *Repository) GetSources() (map[string]Driver, error) {
 	rows, err := r.db.Model(Source{}).Rows()
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	sources := make(map[string]SourceInterface)
+	sources := make(map[string]Driver)
 	for rows.Next() {
-		var source Source
-		err := r.db.ScanRows(rows, &source)
+		var row Source
+		err := r.db.ScanRows(rows, &row)
+		if err != nil {
+			return nil, err
+		}
+
+		driver, err := LoadDriver(row.URL, nil)
 		if err != nil {
 			return nil, err
 		}
 
-		sources[source.Name] = &source
+		if luaSource, ok := driver.(*Source); ok && row.Config != nil {
+			luaSource.Config = row.Config
+			luaSource.RestoreCookies(row.Config.Cookies)
+		}
+
+		sources[row.Name] = driver
 	}
 
 	return sources, nil
 }
 
-func (r *Repository) CreateSource(src SourceInterface) error {
+func (r *Repository) CreateSource(src Driver) error {
 	s, ok := src.(*Source)
 	if !ok {
 		return ErrNotSource
@@ -50,7 +69,7 @@ func (r *Repository) CreateSource(src SourceInterface) error {
 	return r.db.Create(s).Error
 }
 
-func (r *Repository) UpdateSource(src SourceInterface) error {
+func (r *Repository) UpdateSource(src Driver) error {
 	s, ok := src.(*Source)
 	if !ok {
 		return ErrNotSource
@@ -86,6 +105,22 @@ func (r *Repository) SaveSourceConfig(name string, config *Config) error {
 	return tx.Commit().Error
 }
 
+// SaveSourceCookies persists cookies into name's Config, so a session
+// established via Source.Login survives a process restart.
+func (r *Repository) SaveSourceCookies(name string, cookies []*http.Cookie) error {
+	var source Source
+	if err := r.db.Where("name = ?", name).First(&source).Error; err != nil {
+		return err
+	}
+
+	if source.Config == nil {
+		source.Config = &Config{}
+	}
+	source.Config.Cookies = cookies
+
+	return r.db.Table("sources").Where("name = ?", name).Update("config", source.Config).Error
+}
+
 func (r *Repository) UpdateManga(m *Manga) (*Manga, error) {
 	err := r.db.Omit("is_favorite", "created_at").Updates(m).Error
 	if err != nil {
@@ -124,6 +159,10 @@ func (r *Repository) SaveMangaInBatch(mangas []*Manga) ([]*Manga, error) {
 		}
 	}
 
+	for _, m := range mangas {
+		r.invalidateChapterCursors(m.ID)
+	}
+
 	return mangas, nil
 }
 
@@ -177,66 +216,141 @@ func (r *Repository) GetChaptersByMangaID(mangaID uint) ([]*Chapter, error) {
 	return chapters, nil
 }
 
+// GetChapterByID loads a single chapter by id. Prev/Next are resolved from
+// a (cached) ChapterCursor instead of running a LAG/LEAD window function
+// over the whole chapter list on every page load.
 func (r *Repository) GetChapterByID(id uint) (*Chapter, error) {
 	var (
 		source    Source
-		chapter   Chapter = Chapter{}
 		languages []string
-		pages     []*Page
 	)
-	chapter.ID = id
 
-	err := r.db.Model(&chapter).Association("Pages").Find(&pages)
+	err := r.db.Where("name = (?)", r.db.Table("chapters").Select("name").Where("id = ?", id)).First(&source).Error
 	if err != nil {
 		return nil, err
 	}
 
-	err = r.db.First(&chapter, id).Error
-	if err != nil {
-		return nil, err
+	if source.Config != nil && len(source.Config.Language) > 0 {
+		for lang, enabled := range source.Config.Language {
+			if enabled {
+				languages = append(languages, lang)
+			}
+		}
 	}
-	chapter.Pages = pages
 
-	type PrevNext struct {
-		Prev uint `gorm:"prev"`
-		Next uint `gorm:"next"`
+	var mangaID struct {
+		MangaID uint `gorm:"column:manga_id"`
+	}
+	if err := r.db.Model(&Chapter{}).Select("manga_id").Where("id = ?", id).First(&mangaID).Error; err != nil {
+		return nil, err
 	}
 
-	prevNext := PrevNext{}
-
-	err = r.db.Where("name = (?)", r.db.Table("chapters").Select("name").Where("id = ?", id)).First(&source).Error
+	cursor, err := r.GetChapterCursor(mangaID.MangaID, languages)
 	if err != nil {
 		return nil, err
 	}
 
-	if source.Config != nil && len(source.Config.Language) > 0 {
-		for lang, enabled := range source.Config.Language {
-			if enabled {
-				languages = append(languages, lang)
-			}
-		}
+	return r.GetChapterWithNeighbors(id, cursor)
+}
+
+// GetChapterCursor returns the ChapterCursor for (mangaID, languages),
+// building and caching it on first use. Pass a nil or empty languages
+// slice to cover every language.
+func (r *Repository) GetChapterCursor(mangaID uint, languages []string) (*ChapterCursor, error) {
+	key := chapterCursorKey(mangaID, languages)
+
+	r.cursorsMu.RLock()
+	cursor, ok := r.cursors[key]
+	r.cursorsMu.RUnlock()
+	if ok {
+		return cursor, nil
 	}
 
-	subquery := r.db.Select("*, LAG(id, 1, 0) OVER (ORDER BY rank) prev, LEAD(id, 1, 0) OVER (ORDER BY rank) next").Table("chapters").Where("manga_id = ?", chapter.MangaID).Order("rank ASC")
+	db := r.db.Model(&Chapter{}).Select("id, rank").Where("manga_id = ?", mangaID)
 	if len(languages) > 0 {
-		subquery = subquery.Where("language IN ?", languages)
+		db = db.Where("language IN ?", languages)
 	}
-	err = r.db.Select("id, prev, next").Table("(?) AS u", subquery).Where("id = ?", chapter.ID).First(&prevNext).Error
+
+	var entries []chapterRank
+	if err := db.Order("rank ASC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	cursor = newChapterCursor(mangaID, languages, entries)
+
+	r.cursorsMu.Lock()
+	r.cursors[key] = cursor
+	r.cursorsMu.Unlock()
+
+	return cursor, nil
+}
+
+// GetChapterWithNeighbors loads chapter id plus its Prev/Next ids resolved
+// from cursor.
+func (r *Repository) GetChapterWithNeighbors(id uint, cursor *ChapterCursor) (*Chapter, error) {
+	var (
+		chapter Chapter = Chapter{ID: id}
+		pages   []*Page
+	)
+
+	err := r.db.Model(&chapter).Association("Pages").Find(&pages)
 	if err != nil {
 		return nil, err
 	}
 
-	chapter.Prev = prevNext.Prev
-	chapter.Next = prevNext.Next
+	err = r.db.First(&chapter, id).Error
+	if err != nil {
+		return nil, err
+	}
+	chapter.Pages = pages
+	chapter.Prev = cursor.Prev(id)
+	chapter.Next = cursor.Next(id)
 
 	return &chapter, nil
 }
 
+// GetChaptersPage returns up to limit chapters of mangaID ranked strictly
+// after cursor (pass 0 for the first page), for infinite-scroll chapter
+// lists. Chapters with a rank of 0 or below are not on any page reached
+// from cursor 0.
+func (r *Repository) GetChaptersPage(mangaID uint, cursor float64, limit int) ([]*Chapter, error) {
+	var chapters []*Chapter
+	err := r.db.Where("manga_id = ? AND rank > ?", mangaID, cursor).Order("rank ASC").Limit(limit).Find(&chapters).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return chapters, nil
+}
+
+// invalidateChapterCursors drops every cached cursor for mangaID,
+// regardless of language set, so the next GetChapterCursor call rebuilds
+// it from the current chapter list.
+func (r *Repository) invalidateChapterCursors(mangaID uint) {
+	prefix := fmt.Sprintf("%d:", mangaID)
+
+	r.cursorsMu.Lock()
+	defer r.cursorsMu.Unlock()
+	for key := range r.cursors {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.cursors, key)
+		}
+	}
+}
+
+func chapterCursorKey(mangaID uint, languages []string) string {
+	langs := append([]string(nil), languages...)
+	sort.Strings(langs)
+	return fmt.Sprintf("%d:%s", mangaID, strings.Join(langs, ","))
+}
+
 func (r *Repository) SaveChapter(c *Chapter) (*Chapter, error) {
 	err := r.db.Updates(c).Error
 	if err != nil {
 		return nil, err
 	}
 
+	r.invalidateChapterCursors(c.MangaID)
+
 	return c, nil
 }
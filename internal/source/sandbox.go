@@ -0,0 +1,110 @@
+package source
+
+import (
+	"errors"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ErrLuaTimeout is returned when a source script doesn't finish within its
+// configured deadline, e.g. because of a stalled request builder or an
+// accidental infinite loop in a community script.
+var ErrLuaTimeout = errors.New("source: lua script timed out")
+
+// ErrLuaOverLimit is returned when a source script exceeds the configured
+// memory ceiling (MaxAllocMB) or call-stack depth (CallStackSize) while
+// executing.
+var ErrLuaOverLimit = errors.New("source: lua script exceeded its resource limit")
+
+const (
+	defaultLuaTimeout      = 30 * time.Second
+	defaultLuaMaxAllocMB   = 64
+	defaultLuaCallStackMax = 256
+)
+
+// No instruction-count limit.
+//
+// The sandbox does not count executed Lua instructions: gopher-lua has no
+// public API to install a per-instruction (or per-N-instructions) callback
+// like PUC-Rio Lua's lua_sethook. A tight loop that neither allocates past
+// MaxAllocMB nor recurses past CallStackSize (so never trips
+// ErrLuaOverLimit) keeps running until Timeout expires, and is then
+// stopped with ErrLuaTimeout.
+
+// SourceOptions configures the sandbox a source script runs under. The
+// zero value is sane: a 30s deadline, a 64MB allocation ceiling, and the
+// dangerous stdlib modules (os, io, debug, package) never loaded.
+type SourceOptions struct {
+	// Timeout bounds how long a single Lua call may run. Defaults to 30s.
+	// It's also the only defense against a tight infinite loop that never
+	// allocates or recurses enough to trip MaxAllocMB/CallStackSize — see
+	// the "No instruction-count limit" note above.
+	Timeout time.Duration
+	// MaxAllocMB caps the LState's total Lua-heap allocation. Defaults to 64MB.
+	MaxAllocMB int
+	// CallStackSize bounds Lua call recursion depth. Defaults to 256.
+	CallStackSize int
+}
+
+func (o *SourceOptions) withDefaults() *SourceOptions {
+	opts := SourceOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultLuaTimeout
+	}
+	if opts.MaxAllocMB <= 0 {
+		opts.MaxAllocMB = defaultLuaMaxAllocMB
+	}
+	if opts.CallStackSize <= 0 {
+		opts.CallStackSize = defaultLuaCallStackMax
+	}
+	return &opts
+}
+
+// NewSandboxedState builds an LState under the same stdlib/memory/call
+// stack sandbox as source scripts, for other subsystems (like reports)
+// that also execute untrusted Lua. opts may be nil to use the defaults;
+// the resolved options (with defaults applied) are returned alongside the
+// state so the caller can reuse them, e.g. to size its own context
+// timeout.
+func NewSandboxedState(opts *SourceOptions) (*lua.LState, *SourceOptions) {
+	resolved := opts.withDefaults()
+	return newSandboxedState(resolved), resolved
+}
+
+// newSandboxedState creates an LState with only the safe stdlib modules
+// loaded (base, table, string, math, coroutine) and a memory ceiling
+// applied, per opts. os, io, debug and package are never registered, so a
+// source script has no filesystem, process, or introspection access.
+func newSandboxedState(opts *SourceOptions) *lua.LState {
+	l := lua.NewState(lua.Options{
+		SkipOpenLibs:  true,
+		CallStackSize: opts.CallStackSize,
+		MaxAllocBytes: opts.MaxAllocMB * 1024 * 1024,
+	})
+
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.CoroutineLibName, lua.OpenCoroutine},
+	} {
+		l.Push(l.NewFunction(lib.fn))
+		l.Push(lua.LString(lib.name))
+		l.Call(1, 0)
+	}
+
+	// The base library registers dofile and loadfile, which read files
+	// from disk; drop them so scripts keep no filesystem access.
+	l.SetGlobal("dofile", lua.LNil)
+	l.SetGlobal("loadfile", lua.LNil)
+
+	return l
+}
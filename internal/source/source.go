@@ -2,10 +2,16 @@ package source
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
+	neturl "net/url"
+	"strings"
+	"time"
 
 	"github.com/faldez/tanoshi/internal/lua/helper"
 	"github.com/faldez/tanoshi/internal/lua/scraper"
@@ -17,17 +23,51 @@ import (
 type Source struct {
 	Name       string
 	URL        string
+	Config     *Config
 	l          *lua.LState
 	httpClient *http.Client
 	header     http.Header
+	cache      *diskCache
+	cacheTTL   time.Duration
+	opts       *SourceOptions
 }
 
-// LoadSourceFromPath load source from specified path
-func LoadSourceFromPath(path string) (*Source, error) {
-	s := newSource()
+// WithCache enables the on-disk response cache for this source and sets the
+// default TTL used for any operation without a more specific TTL configured
+// in Config.CacheTTL. Passing ttl <= 0 disables expiry, so entries are
+// reused until the cache file is removed.
+func (s *Source) WithCache(ttl time.Duration) *Source {
+	if s.cache == nil {
+		if c, err := newDiskCache(); err == nil {
+			s.cache = c
+		}
+	}
+	s.cacheTTL = ttl
+	return s
+}
+
+func (s *Source) ttlFor(operation string) time.Duration {
+	if s.Config != nil {
+		if ttl, ok := s.Config.CacheTTL[operation]; ok {
+			return ttl
+		}
+	}
+	return s.cacheTTL
+}
+
+// LoadSourceFromPath load source from specified path. opts may be nil, in
+// which case the sandbox defaults (30s timeout, 64MB alloc ceiling, no
+// os/io/debug/package access) apply.
+func LoadSourceFromPath(path string, opts *SourceOptions) (*Source, error) {
+	s := newSource(opts)
 
-	s.httpClient = &http.Client{}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	s.httpClient = &http.Client{Jar: jar}
 	s.header = make(http.Header)
+	s.WithCache(defaultCacheTTL)
 
 	s.l.PreloadModule("scraper", scraper.NewHTMLScraper().Loader)
 	s.l.PreloadModule("helper", helper.NewHelper().Loader)
@@ -36,6 +76,8 @@ func LoadSourceFromPath(path string) (*Source, error) {
 	s.l.SetGlobal(luaMangaTypeName, luar.NewType(s.l, Manga{}))
 	s.l.SetGlobal(luaChapterTypeName, luar.NewType(s.l, Chapter{}))
 	s.l.SetGlobal(luaPageTypeName, luar.NewType(s.l, Page{}))
+	s.l.SetGlobal("set_cookie", s.l.NewFunction(s.luaSetCookie))
+	s.l.SetGlobal("get_cookie", s.l.NewFunction(s.luaGetCookie))
 
 	if err := s.l.DoFile(path); err != nil {
 		return nil, err
@@ -53,8 +95,69 @@ func LoadSourceFromPath(path string) (*Source, error) {
 	return s, nil
 }
 
-func newSource() *Source {
-	return &Source{l: lua.NewState()}
+// Cookies returns the cookies currently held by the source's cookie jar
+// for its base URL, so callers can persist them alongside Config and
+// restore them with RestoreCookies after a restart.
+func (s *Source) Cookies() []*http.Cookie {
+	if s.httpClient == nil || s.httpClient.Jar == nil {
+		return nil
+	}
+	u, err := neturl.Parse(s.URL)
+	if err != nil {
+		return nil
+	}
+	return s.httpClient.Jar.Cookies(u)
+}
+
+// RestoreCookies seeds the source's cookie jar from previously persisted
+// cookies, e.g. right after LoadSourceFromPath, so a login survives a
+// process restart.
+func (s *Source) RestoreCookies(cookies []*http.Cookie) {
+	if len(cookies) == 0 || s.httpClient == nil || s.httpClient.Jar == nil {
+		return
+	}
+	u, err := neturl.Parse(s.URL)
+	if err != nil {
+		return
+	}
+	s.httpClient.Jar.SetCookies(u, cookies)
+}
+
+func (s *Source) luaSetCookie(l *lua.LState) int {
+	rawURL := l.CheckString(1)
+	name := l.CheckString(2)
+	value := l.CheckString(3)
+
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		l.RaiseError("set_cookie: %s", err)
+		return 0
+	}
+
+	s.httpClient.Jar.SetCookies(u, []*http.Cookie{{Name: name, Value: value}})
+	return 0
+}
+
+func (s *Source) luaGetCookie(l *lua.LState) int {
+	rawURL := l.CheckString(1)
+
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		l.RaiseError("get_cookie: %s", err)
+		return 0
+	}
+
+	tbl := l.NewTable()
+	for _, c := range s.httpClient.Jar.Cookies(u) {
+		tbl.RawSetString(c.Name, lua.LString(c.Value))
+	}
+	l.Push(tbl)
+	return 1
+}
+
+func newSource(opts *SourceOptions) *Source {
+	opts = opts.withDefaults()
+	return &Source{l: newSandboxedState(opts), opts: opts}
 }
 
 func (s *Source) getName() error {
@@ -75,7 +178,7 @@ func (s *Source) getBaseURL() error {
 	return nil
 }
 
-func (s *Source) getLatestUpdatesRequest(page int) (*SourceResponse, error) {
+func (s *Source) getLatestUpdatesRequest(ctx context.Context, page int) (*SourceResponse, error) {
 	if err := s.callLuaFunc("get_latest_updates_request", lua.LNumber(page)); err != nil {
 		return nil, err
 	}
@@ -85,7 +188,7 @@ func (s *Source) getLatestUpdatesRequest(page int) (*SourceResponse, error) {
 		return nil, err
 	}
 
-	resp, err := s.doRequest(request)
+	resp, err := s.doRequest(ctx, "get_latest_updates", request)
 	if err != nil {
 		return nil, err
 	}
@@ -114,8 +217,8 @@ func (s *Source) getLatestUpdates(body *string) ([]*Manga, error) {
 }
 
 // GetLatestUpdates get latest updates from source and return list of manga
-func (s *Source) GetLatestUpdates(page int) ([]*Manga, error) {
-	res, err := s.getLatestUpdatesRequest(page)
+func (s *Source) GetLatestUpdates(ctx context.Context, page int) ([]*Manga, error) {
+	res, err := s.getLatestUpdatesRequest(ctx, page)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +231,7 @@ func (s *Source) GetLatestUpdates(page int) ([]*Manga, error) {
 	return mangaList, nil
 }
 
-func (s *Source) getMangaDetailsRequest(m *Manga) (*SourceResponse, error) {
+func (s *Source) getMangaDetailsRequest(ctx context.Context, m *Manga) (*SourceResponse, error) {
 	if err := s.callLuaFunc("get_manga_details_request", luar.New(s.l, *m)); err != nil {
 		return nil, err
 	}
@@ -138,7 +241,7 @@ func (s *Source) getMangaDetailsRequest(m *Manga) (*SourceResponse, error) {
 		return nil, err
 	}
 
-	resp, err := s.doRequest(request)
+	resp, err := s.doRequest(ctx, "get_manga_details", request)
 	if err != nil {
 		return nil, err
 	}
@@ -160,8 +263,8 @@ func (s *Source) getMangaDetails(body *string) (*Manga, error) {
 }
 
 // GetMangaDetails get details for a manga
-func (s *Source) GetMangaDetails(m *Manga) (*Manga, error) {
-	res, err := s.getMangaDetailsRequest(m)
+func (s *Source) GetMangaDetails(ctx context.Context, m *Manga) (*Manga, error) {
+	res, err := s.getMangaDetailsRequest(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +277,7 @@ func (s *Source) GetMangaDetails(m *Manga) (*Manga, error) {
 	return manga, nil
 }
 
-func (s *Source) getChaptersRequest(m *Manga) (*SourceResponse, error) {
+func (s *Source) getChaptersRequest(ctx context.Context, m *Manga) (*SourceResponse, error) {
 	if err := s.callLuaFunc("get_chapters_request", luar.New(s.l, *m)); err != nil {
 		return nil, err
 	}
@@ -184,7 +287,7 @@ func (s *Source) getChaptersRequest(m *Manga) (*SourceResponse, error) {
 		return nil, err
 	}
 
-	resp, err := s.doRequest(req)
+	resp, err := s.doRequest(ctx, "get_chapters", req)
 	if err != nil {
 		return nil, err
 	}
@@ -213,8 +316,8 @@ func (s *Source) getChapters(body *string) ([]*Chapter, error) {
 }
 
 // GetChapters get list of chapter of a manga
-func (s *Source) GetChapters(m *Manga) ([]*Chapter, error) {
-	res, err := s.getChaptersRequest(m)
+func (s *Source) GetChapters(ctx context.Context, m *Manga) ([]*Chapter, error) {
+	res, err := s.getChaptersRequest(ctx, m)
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +329,7 @@ func (s *Source) GetChapters(m *Manga) ([]*Chapter, error) {
 	return chapters, nil
 }
 
-func (s *Source) getChapterRequest(c *Chapter) (*SourceResponse, error) {
+func (s *Source) getChapterRequest(ctx context.Context, c *Chapter) (*SourceResponse, error) {
 	if err := s.callLuaFunc("get_chapter_request", luar.New(s.l, *c)); err != nil {
 		return nil, err
 	}
@@ -236,7 +339,7 @@ func (s *Source) getChapterRequest(c *Chapter) (*SourceResponse, error) {
 		return nil, err
 	}
 
-	resp, err := s.doRequest(req)
+	resp, err := s.doRequest(ctx, "get_chapter", req)
 	if err != nil {
 		return nil, err
 	}
@@ -258,8 +361,8 @@ func (s *Source) getChapter(body *string) (*Chapter, error) {
 }
 
 // GetChapter get detail from a chapter
-func (s *Source) GetChapter(c *Chapter) (*Chapter, error) {
-	res, err := s.getChapterRequest(c)
+func (s *Source) GetChapter(ctx context.Context, c *Chapter) (*Chapter, error) {
+	res, err := s.getChapterRequest(ctx, c)
 	if err != nil {
 		return nil, err
 	}
@@ -272,7 +375,7 @@ func (s *Source) GetChapter(c *Chapter) (*Chapter, error) {
 	return chapter, nil
 }
 
-func (s *Source) loginRequest(username, password, twoFactor string, remember bool) (*SourceResponse, error) {
+func (s *Source) loginRequest(ctx context.Context, username, password, twoFactor string, remember bool) (*SourceResponse, error) {
 	param := map[string]string{
 		"username":    username,
 		"password":    password,
@@ -288,7 +391,7 @@ func (s *Source) loginRequest(username, password, twoFactor string, remember boo
 		return nil, err
 	}
 
-	resp, err := s.doRequest(req)
+	resp, err := s.doRequest(ctx, "login", req)
 	if err != nil {
 		return nil, err
 	}
@@ -319,8 +422,8 @@ func (s *Source) login(resp *SourceResponse) error {
 }
 
 // Login login to source
-func (s *Source) Login(username, password, twoFactor string, remember bool) error {
-	resp, err := s.loginRequest(username, password, twoFactor, remember)
+func (s *Source) Login(ctx context.Context, username, password, twoFactor string, remember bool) error {
+	resp, err := s.loginRequest(ctx, username, password, twoFactor, remember)
 	if err != nil {
 		return err
 	}
@@ -333,7 +436,7 @@ func (s *Source) Login(username, password, twoFactor string, remember bool) erro
 	return nil
 }
 
-func (s *Source) fetchMangaRequest(filter Filters) (*SourceResponse, error) {
+func (s *Source) fetchMangaRequest(ctx context.Context, filter Filters) (*SourceResponse, error) {
 	if err := s.callLuaFunc("fetch_manga_request", filter.ToLTable()); err != nil {
 		return nil, err
 	}
@@ -343,7 +446,7 @@ func (s *Source) fetchMangaRequest(filter Filters) (*SourceResponse, error) {
 		return nil, err
 	}
 
-	resp, err := s.doRequest(req)
+	resp, err := s.doRequest(ctx, "fetch_manga", req)
 	if err != nil {
 		return nil, err
 	}
@@ -370,8 +473,8 @@ func (s *Source) fetchManga(body *string) ([]*Manga, error) {
 	return manga, nil
 }
 
-func (s *Source) FetchManga(filter Filters) ([]*Manga, error) {
-	res, err := s.fetchMangaRequest(filter)
+func (s *Source) FetchManga(ctx context.Context, filter Filters) ([]*Manga, error) {
+	res, err := s.fetchMangaRequest(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -418,25 +521,43 @@ func (s *Source) createRequest() (*http.Request, error) {
 
 	contentType := headerMap.Get("Content-Type")
 	data, dataOk := req.RawGetString("data").(*lua.LTable)
-	if dataOk {
-		switch contentType {
-		case "multipart/form-data":
-			writer := multipart.NewWriter(&buffer)
-
-			data.ForEach(func(k lua.LValue, v lua.LValue) {
-				writer.WriteField(k.String(), v.String())
-			})
-
-			writer.Close()
-			headerMap.Set("Content-Type", writer.FormDataContentType())
-			break
+	body, bodyOk := req.RawGetString("body").(lua.LString)
+
+	switch {
+	case dataOk && contentType == "multipart/form-data":
+		writer := multipart.NewWriter(&buffer)
+
+		data.ForEach(func(k lua.LValue, v lua.LValue) {
+			writer.WriteField(k.String(), v.String())
+		})
+
+		writer.Close()
+		headerMap.Set("Content-Type", writer.FormDataContentType())
+	case dataOk && contentType == "application/x-www-form-urlencoded":
+		values := neturl.Values{}
+		data.ForEach(func(k lua.LValue, v lua.LValue) {
+			values.Set(k.String(), v.String())
+		})
+		buffer.WriteString(values.Encode())
+	case dataOk && contentType == "application/json":
+		raw, err := luajson.Encode(data)
+		if err != nil {
+			return nil, err
 		}
+		buffer.Write(raw)
+	case bodyOk:
+		buffer.WriteString(string(body))
 	}
 
 	method := req.RawGetString("method").String()
-	url := req.RawGetString("url").String()
+	reqURL := req.RawGetString("url").String()
 
-	request, err := http.NewRequest(method, url, &buffer)
+	var bodyReader io.Reader
+	if buffer.Len() > 0 {
+		bodyReader = &buffer
+	}
+
+	request, err := http.NewRequest(method, reqURL, bodyReader)
 	if err != nil {
 		return nil, err
 	}
@@ -445,11 +566,33 @@ func (s *Source) createRequest() (*http.Request, error) {
 	return request, nil
 }
 
-func (s *Source) doRequest(req *http.Request) (*SourceResponse, error) {
+func (s *Source) doRequest(ctx context.Context, operation string, req *http.Request) (*SourceResponse, error) {
+	req = req.WithContext(ctx)
+	noCache := noCacheFromContext(ctx)
+
+	var (
+		key string
+		ttl time.Duration
+	)
+	if cacheEnabled && s.cache != nil && !noCache && req.Method == http.MethodGet {
+		var reqBody []byte
+		if req.Body != nil {
+			reqBody, _ = ioutil.ReadAll(req.Body)
+			req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		ttl = s.ttlFor(operation)
+		key = cacheKey(req, reqBody)
+		if entry, ok := s.cache.get(key, ttl); ok {
+			return &SourceResponse{Header: entry.Header, Body: entry.Body}, nil
+		}
+	}
+
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -461,13 +604,38 @@ func (s *Source) doRequest(req *http.Request) (*SourceResponse, error) {
 		Body:   string(body),
 	}
 
+	if key != "" && isCacheableStatus(resp.StatusCode) {
+		_ = s.cache.put(key, &cacheEntry{
+			StatusCode: resp.StatusCode,
+			Header:     response.Header,
+			Body:       response.Body,
+			StoredAt:   time.Now(),
+		})
+	}
+
 	return &response, nil
 }
 
 func (s *Source) callLuaFunc(name string, args ...lua.LValue) error {
-	return s.l.CallByParam(lua.P{
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	s.l.SetContext(ctx)
+
+	err := s.l.CallByParam(lua.P{
 		Fn:      s.l.GetGlobal(name),
 		NRet:    1,
 		Protect: true,
 	}, args...)
+
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return ErrLuaTimeout
+		}
+		if strings.Contains(err.Error(), "not enough memory") || strings.Contains(err.Error(), "stack overflow") {
+			return ErrLuaOverLimit
+		}
+		return err
+	}
+
+	return nil
 }